@@ -0,0 +1,80 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+)
+
+// StatusUpdate is a single deposit status transition, as observed by the
+// exchange/scanner subsystems (waiting_deposit -> waiting_confirm -> done,
+// or an error). It's the event type fanned out by StatusPublisher and
+// translated by proxy.gateway.SubscribeDepositStatus into the wire-format
+// proxy.StatusUpdate pushed over /api/status/subscribe.
+type StatusUpdate struct {
+	Seq       int
+	UpdatedAt int64
+	Status    string
+	Error     string
+}
+
+// StatusPublisher fans out deposit status transitions to subscribers, keyed
+// by skyaddr. The exchange and scanner subsystems call Publish whenever a
+// deposit's state changes; SubscribeDepositStatus hands each caller its own
+// buffered channel of updates for one skyaddr, replacing the previous
+// client polling pattern against /api/status.
+type StatusPublisher struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan StatusUpdate]struct{}
+}
+
+// NewStatusPublisher returns an empty StatusPublisher ready to use.
+func NewStatusPublisher() *StatusPublisher {
+	return &StatusPublisher{
+		subscribers: make(map[string]map[chan StatusUpdate]struct{}),
+	}
+}
+
+// SubscribeDepositStatus returns a channel of StatusUpdate events for
+// skyaddr. The channel is closed, and the subscription removed, when ctx is
+// done.
+func (p *StatusPublisher) SubscribeDepositStatus(ctx context.Context, skyaddr string) (<-chan StatusUpdate, error) {
+	ch := make(chan StatusUpdate, 1)
+
+	p.mu.Lock()
+	if p.subscribers[skyaddr] == nil {
+		p.subscribers[skyaddr] = make(map[chan StatusUpdate]struct{})
+	}
+	p.subscribers[skyaddr][ch] = struct{}{}
+	p.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		p.mu.Lock()
+		delete(p.subscribers[skyaddr], ch)
+		if len(p.subscribers[skyaddr]) == 0 {
+			delete(p.subscribers, skyaddr)
+		}
+		p.mu.Unlock()
+
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+// Publish notifies every current subscriber of skyaddr's deposit that its
+// status has transitioned to update. Subscribers that aren't receiving fast
+// enough are skipped for this update rather than blocking the caller, since
+// exchange/scanner state transitions must not stall on a slow client.
+func (p *StatusPublisher) Publish(skyaddr string, update StatusUpdate) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for ch := range p.subscribers[skyaddr] {
+		select {
+		case ch <- update:
+		default:
+		}
+	}
+}
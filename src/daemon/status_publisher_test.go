@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStatusPublisherPublishDeliversToSubscriber(t *testing.T) {
+	p := NewStatusPublisher()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := p.SubscribeDepositStatus(ctx, "addr1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p.Publish("addr1", StatusUpdate{Seq: 1, Status: "waiting_confirm"})
+
+	select {
+	case u := <-ch:
+		if u.Status != "waiting_confirm" {
+			t.Errorf("got status %q, want waiting_confirm", u.Status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for published update")
+	}
+}
+
+func TestStatusPublisherPublishIgnoresOtherSkyaddrs(t *testing.T) {
+	p := NewStatusPublisher()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := p.SubscribeDepositStatus(ctx, "addr1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p.Publish("addr2", StatusUpdate{Seq: 1, Status: "waiting_confirm"})
+
+	select {
+	case u := <-ch:
+		t.Fatalf("unexpected update for unrelated skyaddr: %+v", u)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestStatusPublisherClosesChannelOnContextDone(t *testing.T) {
+	p := NewStatusPublisher()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch, err := p.SubscribeDepositStatus(ctx, "addr1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("expected channel to be closed, got a value instead")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for channel to close")
+	}
+}
@@ -0,0 +1,27 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// allowedHostsPolicy builds an autocert.HostPolicy that only allows
+// certificate issuance for the given hostnames, rejecting everything else.
+// Unlike autocert.HostWhitelist, it accepts the hosts as a slice so that
+// multiple Teller deployments sharing one TLSCertCache can serve distinct
+// hostnames from the same certificate cache.
+func allowedHostsPolicy(hosts []string) autocert.HostPolicy {
+	allowed := make(map[string]bool, len(hosts))
+	for _, h := range hosts {
+		allowed[h] = true
+	}
+
+	return func(ctx context.Context, host string) error {
+		if allowed[host] {
+			return nil
+		}
+		return fmt.Errorf("acme/autocert: host %q not configured in AutoTLSHosts", host)
+	}
+}
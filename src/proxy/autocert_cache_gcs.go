@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"context"
+	"io/ioutil"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/crypto/acme/autocert"
+	"google.golang.org/api/iterator"
+)
+
+// GCSCache is an autocert.Cache backed by a Google Cloud Storage bucket, for
+// the same reason as S3Cache: autocert state should survive ephemeral
+// filesystems and be shared across Teller instances behind a load balancer.
+type GCSCache struct {
+	Bucket string
+	Prefix string
+
+	client *storage.Client
+}
+
+// NewGCSCache creates a GCSCache for the given bucket and object prefix,
+// using application default credentials.
+func NewGCSCache(ctx context.Context, bucket, prefix string) (*GCSCache, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCSCache{
+		Bucket: bucket,
+		Prefix: prefix,
+		client: client,
+	}, nil
+}
+
+func (c *GCSCache) key(name string) string {
+	if c.Prefix == "" {
+		return name
+	}
+	return c.Prefix + "/" + name
+}
+
+func (c *GCSCache) object(name string) *storage.ObjectHandle {
+	return c.client.Bucket(c.Bucket).Object(c.key(name))
+}
+
+// Get implements autocert.Cache.
+func (c *GCSCache) Get(ctx context.Context, name string) ([]byte, error) {
+	r, err := c.object(name).NewReader(ctx)
+	if err != nil {
+		if err == storage.ErrObjectNotExist || err == iterator.Done {
+			return nil, autocert.ErrCacheMiss
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+// Put implements autocert.Cache.
+func (c *GCSCache) Put(ctx context.Context, name string, data []byte) error {
+	w := c.object(name).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Delete implements autocert.Cache.
+func (c *GCSCache) Delete(ctx context.Context, name string) error {
+	err := c.object(name).Delete(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil
+	}
+	return err
+}
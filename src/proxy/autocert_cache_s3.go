@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// S3Cache is an autocert.Cache backed by an S3 bucket, so that autocert state
+// (account keys and issued certs) survives ephemeral filesystems and can be
+// shared by multiple Teller instances behind a load balancer.
+type S3Cache struct {
+	Bucket string
+	Prefix string
+
+	client     *s3.S3
+	uploader   *s3manager.Uploader
+	downloader *s3manager.Downloader
+}
+
+// NewS3Cache creates an S3Cache for the given bucket and key prefix, using
+// the default AWS session (environment variables, shared config, or an
+// attached IAM role).
+func NewS3Cache(bucket, prefix string) (*S3Cache, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Cache{
+		Bucket:     bucket,
+		Prefix:     prefix,
+		client:     s3.New(sess),
+		uploader:   s3manager.NewUploader(sess),
+		downloader: s3manager.NewDownloader(sess),
+	}, nil
+}
+
+func (c *S3Cache) key(name string) string {
+	if c.Prefix == "" {
+		return name
+	}
+	return c.Prefix + "/" + name
+}
+
+// Get implements autocert.Cache.
+func (c *S3Cache) Get(ctx context.Context, name string) ([]byte, error) {
+	buf := aws.NewWriteAtBuffer(nil)
+	_, err := c.downloader.DownloadWithContext(ctx, buf, &s3.GetObjectInput{
+		Bucket: aws.String(c.Bucket),
+		Key:    aws.String(c.key(name)),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, autocert.ErrCacheMiss
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Put implements autocert.Cache.
+func (c *S3Cache) Put(ctx context.Context, name string, data []byte) error {
+	_, err := c.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(c.Bucket),
+		Key:    aws.String(c.key(name)),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+// Delete implements autocert.Cache.
+func (c *S3Cache) Delete(ctx context.Context, name string) error {
+	_, err := c.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(c.Bucket),
+		Key:    aws.String(c.key(name)),
+	})
+	return err
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerifyCaptchaSkippedWhenSecretUnset(t *testing.T) {
+	srv := &httpServ{}
+
+	ok, err := verifyCaptcha(srv, "203.0.113.9", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Error("expected verification to be skipped (true) when CaptchaSecret is unset")
+	}
+}
+
+func TestVerifyCaptchaUnknownProvider(t *testing.T) {
+	srv := &httpServ{CaptchaSecret: "secret", CaptchaProvider: "not-a-provider"}
+
+	if _, err := verifyCaptcha(srv, "203.0.113.9", "response"); err == nil {
+		t.Error("expected an error for an unconfigured captcha provider")
+	}
+}
+
+func withStubProvider(t *testing.T, provider string, success bool) *httpServ {
+	t.Helper()
+
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(captchaVerifyResponse{Success: success})
+	}))
+	t.Cleanup(stub.Close)
+
+	orig := captchaVerifyURLs[provider]
+	captchaVerifyURLs[provider] = stub.URL
+	t.Cleanup(func() { captchaVerifyURLs[provider] = orig })
+
+	return &httpServ{CaptchaSecret: "secret", CaptchaProvider: provider}
+}
+
+func TestVerifyCaptchaSuccess(t *testing.T) {
+	srv := withStubProvider(t, "hcaptcha", true)
+
+	ok, err := verifyCaptcha(srv, "203.0.113.9", "good-response")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Error("expected verification to succeed")
+	}
+}
+
+func TestVerifyCaptchaFailure(t *testing.T) {
+	srv := withStubProvider(t, "hcaptcha", false)
+
+	ok, err := verifyCaptcha(srv, "203.0.113.9", "bad-response")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Error("expected verification to fail when the provider reports success=false")
+	}
+}
+
+// TestBindHandlerCaptchaRejection exercises the same call BindHandler makes
+// for its captcha check (http.go:474-480), using clientIP to derive the
+// remoteip the way the handler does.
+func TestBindHandlerCaptchaRejection(t *testing.T) {
+	srv := withStubProvider(t, "hcaptcha", false)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/bind", nil)
+	r.RemoteAddr = "203.0.113.9:54321"
+
+	ok, err := verifyCaptcha(srv, clientIP(r), "whatever-the-client-sent")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Error("expected BindHandler's captcha check to reject a failed verification")
+	}
+}
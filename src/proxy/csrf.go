@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/skycoin/teller/src/util/httputil"
+)
+
+const csrfTokenLifetime = time.Minute * 10
+
+// csrfToken is a signed, short-lived token issued by /api/csrf and required
+// in the X-CSRF-Token header on /api/bind. It binds the token to the
+// requesting IP and an expiry, so a token lifted from one client can't be
+// replayed indefinitely from another.
+type csrfToken struct {
+	expiry   int64
+	clientIP string
+}
+
+func (t csrfToken) sign(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d:%s", t.expiry, t.clientIP)
+	sig := mac.Sum(nil)
+
+	payload := make([]byte, 8+len(sig))
+	binary.BigEndian.PutUint64(payload, uint64(t.expiry))
+	copy(payload[8:], sig)
+
+	return base64.URLEncoding.EncodeToString(payload)
+}
+
+func verifyCSRFToken(key []byte, clientIP, token string) bool {
+	if len(key) == 0 {
+		// Fail closed: an empty key would make the HMAC trivially
+		// forgeable, so an unconfigured CSRFTokenKey must never be
+		// treated as "CSRF protection disabled".
+		return false
+	}
+
+	raw, err := base64.URLEncoding.DecodeString(token)
+	if err != nil || len(raw) < 9 {
+		return false
+	}
+
+	expiry := int64(binary.BigEndian.Uint64(raw[:8]))
+	if time.Now().Unix() > expiry {
+		return false
+	}
+
+	want := csrfToken{expiry: expiry, clientIP: clientIP}
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d:%s", want.expiry, want.clientIP)
+	sig := mac.Sum(nil)
+
+	return subtle.ConstantTimeCompare(sig, raw[8:]) == 1
+}
+
+// CSRFHandler issues a signed token bound to the caller's IP, valid for
+// csrfTokenLifetime. The token must be echoed back in the X-CSRF-Token
+// header of a subsequent /api/bind request.
+// Method: GET
+// URI: /api/csrf
+func CSRFHandler(srv *httpServ) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validMethod(w, r, srv.Gateway, []string{http.MethodGet}) {
+			return
+		}
+
+		if len(srv.CSRFTokenKey) == 0 {
+			// Fail closed rather than issue a token that would be
+			// trivially forgeable with an empty HMAC key.
+			errorResponse(w, srv.Gateway, http.StatusInternalServerError, "CSRFTokenKey is not configured")
+			return
+		}
+
+		token := csrfToken{
+			expiry:   time.Now().Add(csrfTokenLifetime).Unix(),
+			clientIP: clientIP(r),
+		}
+
+		if err := httputil.JSONResponse(w, map[string]string{
+			"csrf_token": token.sign(srv.CSRFTokenKey),
+		}); err != nil {
+			srv.Println(err)
+		}
+	}
+}
+
+// clientIP returns the real client address, with the ephemeral source port
+// stripped, for CSRF binding and captcha verification. RemoteAddr is
+// authoritative: when ProxyProtocol is enabled (chunk0-2), it's already been
+// rewritten to the real client IP by the PROXY protocol listener, so the
+// client-controlled X-Forwarded-For header must not be trusted here.
+//
+// The port must be stripped: a CSRF token binds to this value for up to
+// csrfTokenLifetime, far longer than a client's TCP connection is expected
+// to live, so binding to "ip:port" would reject legitimate bind requests
+// the moment the client opens a new connection.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
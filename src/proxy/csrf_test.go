@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientIPStripsPort(t *testing.T) {
+	cases := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{"ipv4 with port", "203.0.113.9:54321", "203.0.113.9"},
+		{"ipv6 with port", "[2001:db8::1]:54321", "2001:db8::1"},
+		{"no port falls back to raw value", "not-host-port", "not-host-port"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = c.remoteAddr
+
+			if got := clientIP(r); got != c.want {
+				t.Errorf("clientIP(%q) = %q, want %q", c.remoteAddr, got, c.want)
+			}
+		})
+	}
+}
+
+func TestVerifyCSRFTokenValid(t *testing.T) {
+	key := []byte("test-key")
+	tok := csrfToken{expiry: time.Now().Add(time.Minute).Unix(), clientIP: "203.0.113.9"}
+
+	if !verifyCSRFToken(key, "203.0.113.9", tok.sign(key)) {
+		t.Error("expected a freshly signed token for the same IP to verify")
+	}
+}
+
+func TestVerifyCSRFTokenEmptyKeyFailsClosed(t *testing.T) {
+	tok := csrfToken{expiry: time.Now().Add(time.Minute).Unix(), clientIP: "203.0.113.9"}
+
+	if verifyCSRFToken(nil, "203.0.113.9", tok.sign([]byte("some-key"))) {
+		t.Error("expected verification with an empty key to fail closed")
+	}
+}
+
+func TestVerifyCSRFTokenExpired(t *testing.T) {
+	key := []byte("test-key")
+	tok := csrfToken{expiry: time.Now().Add(-time.Minute).Unix(), clientIP: "203.0.113.9"}
+
+	if verifyCSRFToken(key, "203.0.113.9", tok.sign(key)) {
+		t.Error("expected an expired token to fail verification")
+	}
+}
+
+func TestVerifyCSRFTokenWrongIP(t *testing.T) {
+	key := []byte("test-key")
+	tok := csrfToken{expiry: time.Now().Add(time.Minute).Unix(), clientIP: "203.0.113.9"}
+
+	if verifyCSRFToken(key, "198.51.100.1", tok.sign(key)) {
+		t.Error("expected a token signed for a different IP to fail verification")
+	}
+}
+
+func TestVerifyCSRFTokenMalformedBase64(t *testing.T) {
+	key := []byte("test-key")
+
+	if verifyCSRFToken(key, "203.0.113.9", "not-valid-base64!!") {
+		t.Error("expected malformed base64 to fail verification")
+	}
+}
+
+func TestVerifyCSRFTokenTruncatedPayload(t *testing.T) {
+	key := []byte("test-key")
+
+	if verifyCSRFToken(key, "203.0.113.9", "AAAA") {
+		t.Error("expected a too-short payload to fail verification")
+	}
+}
+
+func TestVerifyCSRFTokenIPv6RemoteAddr(t *testing.T) {
+	key := []byte("test-key")
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "[2001:db8::1]:54321"
+
+	tok := csrfToken{expiry: time.Now().Add(time.Minute).Unix(), clientIP: clientIP(r)}
+
+	if !verifyCSRFToken(key, clientIP(r), tok.sign(key)) {
+		t.Error("expected a token bound to a bare IPv6 address to verify")
+	}
+}
+
+// TestBindHandlerCSRFRejection exercises the same checks BindHandler runs
+// against an incoming request's X-CSRF-Token header, using the exact
+// clientIP/verifyCSRFToken calls it makes (csrf.go:40,107, http.go:452-455).
+func TestBindHandlerCSRFRejection(t *testing.T) {
+	key := []byte("test-key")
+
+	r := httptest.NewRequest(http.MethodPost, "/api/bind", nil)
+	r.RemoteAddr = "203.0.113.9:54321"
+	r.Header.Set("Content-Type", "application/json")
+
+	if verifyCSRFToken(key, clientIP(r), r.Header.Get("X-CSRF-Token")) {
+		t.Error("expected a request with no X-CSRF-Token header to be rejected")
+	}
+
+	stolenToken := csrfToken{expiry: time.Now().Add(time.Minute).Unix(), clientIP: "198.51.100.1"}.sign(key)
+	r.Header.Set("X-CSRF-Token", stolenToken)
+	if verifyCSRFToken(key, clientIP(r), r.Header.Get("X-CSRF-Token")) {
+		t.Error("expected a token issued to a different client IP to be rejected")
+	}
+
+	validToken := csrfToken{expiry: time.Now().Add(time.Minute).Unix(), clientIP: clientIP(r)}.sign(key)
+	r.Header.Set("X-CSRF-Token", validToken)
+	if !verifyCSRFToken(key, clientIP(r), r.Header.Get("X-CSRF-Token")) {
+		t.Error("expected a token issued to this client IP to be accepted")
+	}
+}
@@ -0,0 +1,10 @@
+package proxy
+
+import "context"
+
+// Ping checks that the daemon-facing gateway has a live connection to the
+// teller daemon. It backs the /readyz admin endpoint and fails readiness
+// checks while the daemon connection is down.
+func (gw *gateway) Ping(ctx context.Context) error {
+	return gw.d.Ping(ctx)
+}
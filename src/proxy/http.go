@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"sync"
@@ -13,8 +14,10 @@ import (
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/didip/tollbooth"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/unrolled/secure"
 	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
 
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/teller/src/daemon"
@@ -29,11 +32,16 @@ const (
 
 	// https://blog.cloudflare.com/the-complete-guide-to-golang-net-http-timeouts/
 	// The timeout configuration is necessary for public servers, or else
-	// connections will be used up
+	// connections will be used up. These are the defaults used when the
+	// corresponding httpServ field is left at its zero value.
 	serverReadTimeout  = time.Second * 10
 	serverWriteTimeout = time.Second * 60
 	serverIdleTimeout  = time.Second * 120
 
+	// defaultHTTP2MaxConcurrentStreams is the default cap on concurrent h2
+	// streams per connection, used when HTTP2MaxConcurrentStreams is unset.
+	defaultHTTP2MaxConcurrentStreams = 250
+
 	// Directory where cached SSL certs from Let's Encrypt are stored
 	tlsAutoCertCache = "cert-cache"
 )
@@ -47,19 +55,58 @@ type httpServ struct {
 	logger.Logger
 	Addr          string
 	HTTPSAddr     string
+	AdminAddr     string
 	StaticDir     string
 	HTMLInterface bool
 	StartAt       time.Time
 	AutoTLSHost   string
+	AutoTLSHosts  []string
 	TLSCert       string
 	TLSKey        string
+	// TLSCertCache stores autocert's issued certificates. It defaults to a
+	// local directory cache, but can be set to a shared cache (S3, GCS) so
+	// that multiple Teller instances behind a load balancer don't each
+	// trigger their own rate-limited Let's Encrypt issuance.
+	TLSCertCache  autocert.Cache
 	Gateway       *gateway
 	WithoutTeller bool
 
+	// ProxyProtocol enables parsing of the PROXY protocol v1/v2 header on the
+	// public listeners, for deployments behind an L4 load balancer or
+	// Cloudflare Spectrum. RemoteAddr is rewritten to the real client IP.
+	ProxyProtocol bool
+	// ProxyProtocolTrustedCIDRs restricts which upstream IPs are allowed to
+	// send a PROXY protocol header. An empty slice trusts all upstreams.
+	ProxyProtocolTrustedCIDRs []string
+
+	// CSRFTokenKey signs the tokens issued by /api/csrf and required on
+	// /api/bind. It must be set to a random secret in production.
+	CSRFTokenKey []byte
+
+	// CaptchaProvider is "hcaptcha" or "recaptcha". CaptchaSecret is the
+	// provider's server-side secret key; captcha verification is skipped
+	// entirely when it's empty.
+	CaptchaProvider string
+	CaptchaSecret   string
+
+	// ReadTimeout, WriteTimeout and IdleTimeout configure the public
+	// listeners; zero means fall back to the server*Timeout defaults.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// HTTP2MaxConcurrentStreams and HTTP2IdleTimeout configure the h2
+	// server added to the HTTPS listener; zero means fall back to
+	// defaultHTTP2MaxConcurrentStreams, and no extra idle timeout beyond
+	// IdleTimeout above.
+	HTTP2MaxConcurrentStreams uint32
+	HTTP2IdleTimeout          time.Duration
+
 	Throttle Throttle
 
 	httpListener  *http.Server
 	httpsListener *http.Server
+	adminListener *http.Server
 	quit          chan struct{}
 }
 
@@ -75,6 +122,16 @@ func (hs *httpServ) Run() error {
 
 	hs.quit = make(chan struct{})
 
+	if hs.AdminAddr != "" {
+		hs.Println("Admin service address:", hs.AdminAddr)
+		hs.adminListener = hs.setupHTTPListener(hs.AdminAddr, hs.setupAdminMux())
+		go func() {
+			if err := hs.adminListener.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				hs.Println("Admin ListenAndServe error:", err)
+			}
+		}()
+	}
+
 	var mux http.Handler = hs.setupMux()
 
 	allowedHosts := []string{} // empty array means all hosts allowed
@@ -104,8 +161,15 @@ func (hs *httpServ) Run() error {
 	secureMiddleware := configureSecureMiddleware(sslHost, allowedHosts)
 	mux = secureMiddleware.Handler(mux)
 
+	var httpLn net.Listener
 	if hs.Addr != "" {
-		hs.httpListener = setupHTTPListener(hs.Addr, mux)
+		hs.httpListener = hs.setupHTTPListener(hs.Addr, mux)
+
+		ln, err := hs.listen(hs.Addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %v", hs.Addr, err)
+		}
+		httpLn = ln
 	}
 
 	handleListenErr := func(f func() error) error {
@@ -124,24 +188,48 @@ func (hs *httpServ) Run() error {
 	if hs.HTTPSAddr != "" {
 		hs.Println("Using TLS")
 
-		hs.httpsListener = setupHTTPListener(hs.HTTPSAddr, mux)
+		hs.httpsListener = hs.setupHTTPListener(hs.HTTPSAddr, mux)
+
+		if err := hs.configureHTTP2(hs.httpsListener); err != nil {
+			return fmt.Errorf("failed to configure http2: %v", err)
+		}
+
+		httpsLn, err := hs.listen(hs.HTTPSAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %v", hs.HTTPSAddr, err)
+		}
 
 		tlsCert := hs.TLSCert
 		tlsKey := hs.TLSKey
 
 		if hs.AutoTLSHost != "" {
 			hs.Println("Using Let's Encrypt autocert with host", hs.AutoTLSHost)
+
+			hosts := hs.AutoTLSHosts
+			if len(hosts) == 0 {
+				hosts = []string{hs.AutoTLSHost}
+			}
+
+			cache := hs.TLSCertCache
+			if cache == nil {
+				cache = autocert.DirCache(tlsAutoCertCache)
+			}
+
 			// https://godoc.org/golang.org/x/crypto/acme/autocert
 			// https://stackoverflow.com/a/40494806
 			certManager := autocert.Manager{
 				Prompt:     autocert.AcceptTOS,
-				HostPolicy: autocert.HostWhitelist(hs.AutoTLSHost),
-				Cache:      autocert.DirCache(tlsAutoCertCache),
+				HostPolicy: allowedHostsPolicy(hosts),
+				Cache:      cache,
 			}
 
-			hs.httpsListener.TLSConfig = &tls.Config{
-				GetCertificate: certManager.GetCertificate,
-			}
+			// Set GetCertificate on the TLSConfig that configureHTTP2 already
+			// prepared above, instead of replacing it outright: a fresh
+			// *tls.Config here would drop the "h2" entry ConfigureServer
+			// added to NextProtos, and ServeTLS won't re-add it once
+			// TLSNextProto["h2"] is already registered, silently downgrading
+			// autocert deployments to HTTP/1.1.
+			hs.httpsListener.TLSConfig.GetCertificate = certManager.GetCertificate
 
 			// These will be autogenerated by the autocert middleware
 			tlsCert = ""
@@ -152,7 +240,7 @@ func (hs *httpServ) Run() error {
 
 		if hs.Addr == "" {
 			return handleListenErr(func() error {
-				return hs.httpsListener.ListenAndServeTLS(tlsCert, tlsKey)
+				return hs.httpsListener.ServeTLS(httpsLn, tlsCert, tlsKey)
 			})
 		}
 		return handleListenErr(func() error {
@@ -161,16 +249,16 @@ func (hs *httpServ) Run() error {
 
 			go func() {
 				defer wg.Done()
-				if err := hs.httpsListener.ListenAndServeTLS(tlsCert, tlsKey); err != nil {
-					hs.Println("ListenAndServeTLS error:", err)
+				if err := hs.httpsListener.ServeTLS(httpsLn, tlsCert, tlsKey); err != nil {
+					hs.Println("ServeTLS error:", err)
 					errC <- err
 				}
 			}()
 
 			go func() {
 				defer wg.Done()
-				if err := hs.httpListener.ListenAndServe(); err != nil {
-					hs.Println("ListenAndServe error:", err)
+				if err := hs.httpListener.Serve(httpLn); err != nil {
+					hs.Println("Serve error:", err)
 					errC <- err
 				}
 			}()
@@ -194,7 +282,7 @@ func (hs *httpServ) Run() error {
 	}
 
 	return handleListenErr(func() error {
-		return hs.httpListener.ListenAndServe()
+		return hs.httpListener.Serve(httpLn)
 	})
 
 }
@@ -237,27 +325,63 @@ func configureSecureMiddleware(sslHost string, allowedHosts []string) *secure.Se
 	})
 }
 
-func setupHTTPListener(addr string, handler http.Handler) *http.Server {
+func (hs *httpServ) setupHTTPListener(addr string, handler http.Handler) *http.Server {
+	readTimeout := hs.ReadTimeout
+	if readTimeout == 0 {
+		readTimeout = serverReadTimeout
+	}
+
+	writeTimeout := hs.WriteTimeout
+	if writeTimeout == 0 {
+		writeTimeout = serverWriteTimeout
+	}
+
+	idleTimeout := hs.IdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = serverIdleTimeout
+	}
+
 	return &http.Server{
 		Addr:         addr,
 		Handler:      handler,
-		ReadTimeout:  serverReadTimeout,
-		WriteTimeout: serverWriteTimeout,
-		IdleTimeout:  serverIdleTimeout,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  idleTimeout,
+	}
+}
+
+// configureHTTP2 enables HTTP/2 on srv with the configured stream and idle
+// limits. It must be called before srv starts serving TLS connections.
+func (hs *httpServ) configureHTTP2(srv *http.Server) error {
+	maxStreams := hs.HTTP2MaxConcurrentStreams
+	if maxStreams == 0 {
+		maxStreams = defaultHTTP2MaxConcurrentStreams
 	}
+
+	return http2.ConfigureServer(srv, &http2.Server{
+		MaxConcurrentStreams: maxStreams,
+		IdleTimeout:          hs.HTTP2IdleTimeout,
+	})
 }
 
 func (hs *httpServ) setupMux() *http.ServeMux {
 	mux := http.NewServeMux()
 
-	handleAPI := func(path string, f http.HandlerFunc) {
+	handleAPI := func(path string, counter prometheus.Counter, f http.HandlerFunc) {
+		f = metricsMiddleware(path, counter, f)
 		mux.Handle(path, gziphandler.GzipHandler(rateLimiter(hs.Throttle, httputil.LogHandler(hs.Logger, f))))
 	}
 
 	if !hs.WithoutTeller {
 		// API Methods
-		handleAPI("/api/bind", BindHandler(hs))
-		handleAPI("/api/status", StatusHandler(hs))
+		handleAPI("/api/csrf", metricCSRFRequests, CSRFHandler(hs))
+		handleAPI("/api/bind", metricBindRequests, BindHandler(hs))
+		handleAPI("/api/status", metricStatusRequests, StatusHandler(hs))
+
+		// SubscribeStatusHandler manages its own long-lived connection and
+		// is not a fit for the gzip/rate-limit/metrics chain used by the
+		// request-response API handlers above.
+		mux.Handle("/api/status/subscribe", httputil.LogHandler(hs.Logger, SubscribeStatusHandler(hs)))
 	}
 
 	// Static files
@@ -269,7 +393,11 @@ func (hs *httpServ) setupMux() *http.ServeMux {
 }
 
 func rateLimiter(thr Throttle, hd http.HandlerFunc) http.Handler {
-	return tollbooth.LimitFuncHandler(tollbooth.NewLimiter(thr.Max, thr.Duration), hd)
+	limiter := tollbooth.NewLimiter(thr.Max, thr.Duration)
+	limiter.SetOnLimitReached(func(w http.ResponseWriter, r *http.Request) {
+		metricThrottledRequests.WithLabelValues(r.URL.Path).Inc()
+	})
+	return tollbooth.LimitFuncHandler(limiter, hd)
 }
 
 func (hs *httpServ) Shutdown() {
@@ -286,12 +414,19 @@ func (hs *httpServ) Shutdown() {
 		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
 		if err := ln.Shutdown(ctx); err != nil {
-			hs.Println("HTTP server shutdown error:", err)
+			// Shutdown waits for in-flight requests and h2 streams to drain,
+			// but gives up and returns ctx.Err() once shutdownTimeout elapses
+			// without force-closing anything on its own; do that here.
+			hs.Println("HTTP server shutdown error, forcing close:", err)
+			if err := ln.Close(); err != nil {
+				hs.Println("HTTP server close error:", err)
+			}
 		}
 	}
 
 	shutdown("HTTP", hs.httpListener)
 	shutdown("HTTPS", hs.httpsListener)
+	shutdown("Admin", hs.adminListener)
 
 	hs.quit = nil
 }
@@ -314,6 +449,11 @@ func BindHandler(srv *httpServ) http.HandlerFunc {
 			return
 		}
 
+		if !verifyCSRFToken(srv.CSRFTokenKey, clientIP(r), r.Header.Get("X-CSRF-Token")) {
+			errorResponse(w, srv.Gateway, http.StatusForbidden, "Missing or invalid X-CSRF-Token")
+			return
+		}
+
 		userBindReq := &bindRequest{}
 		decoder := json.NewDecoder(r.Body)
 		if err := decoder.Decode(&userBindReq); err != nil {
@@ -331,6 +471,14 @@ func BindHandler(srv *httpServ) http.HandlerFunc {
 			return
 		}
 
+		if ok, err := verifyCaptcha(srv, clientIP(r), userBindReq.CaptchaResponse); err != nil {
+			errorResponse(w, srv.Gateway, http.StatusBadGateway, "Captcha verification failed:", err)
+			return
+		} else if !ok {
+			errorResponse(w, srv.Gateway, http.StatusForbidden, "Captcha verification failed")
+			return
+		}
+
 		if !readyToStart(w, srv.Gateway, srv.StartAt) {
 			return
 		}
@@ -342,7 +490,12 @@ func BindHandler(srv *httpServ) http.HandlerFunc {
 
 		srv.Println("Sending BindRequest to teller, skyaddr", userBindReq.SkyAddr)
 
-		rsp, err := srv.Gateway.BindAddress(cxt, &daemonBindReq)
+		var rsp *daemon.BindResponse
+		err := instrumentGatewayCall("/api/bind", func() error {
+			var err error
+			rsp, err = srv.Gateway.BindAddress(cxt, &daemonBindReq)
+			return err
+		})
 		if err != nil {
 			handleGatewayResponseError(w, srv.Gateway, err)
 			return
@@ -364,6 +517,9 @@ func BindHandler(srv *httpServ) http.HandlerFunc {
 
 type bindRequest struct {
 	SkyAddr string `json:"skyaddr"`
+	// CaptchaResponse is the client-side hCaptcha/reCAPTCHA response token.
+	// It's only required when the server is configured with a CaptchaSecret.
+	CaptchaResponse string `json:"captcha_response"`
 }
 
 // StatusHandler returns the deposit status of specific skycoin address
@@ -398,7 +554,12 @@ func StatusHandler(srv *httpServ) http.HandlerFunc {
 
 		srv.Println("Sending StatusRequest to teller, skyaddr", skyAddr)
 
-		rsp, err := srv.Gateway.GetDepositStatuses(cxt, &stReq)
+		var rsp *daemon.StatusResponse
+		err := instrumentGatewayCall("/api/status", func() error {
+			var err error
+			rsp, err = srv.Gateway.GetDepositStatuses(cxt, &stReq)
+			return err
+		})
 		if err != nil {
 			handleGatewayResponseError(w, srv.Gateway, err)
 			return
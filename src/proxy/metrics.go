@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	metricBindRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "teller",
+		Subsystem: "proxy",
+		Name:      "bind_requests_total",
+		Help:      "Total number of /api/bind requests received",
+	})
+
+	metricStatusRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "teller",
+		Subsystem: "proxy",
+		Name:      "status_requests_total",
+		Help:      "Total number of /api/status requests received",
+	})
+
+	metricCSRFRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "teller",
+		Subsystem: "proxy",
+		Name:      "csrf_token_requests_total",
+		Help:      "Total number of /api/csrf token requests received",
+	})
+
+	metricGatewayErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teller",
+		Subsystem: "proxy",
+		Name:      "gateway_errors_total",
+		Help:      "Total number of errors returned by the daemon gateway, by route",
+	}, []string{"route"})
+
+	metricGatewayLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "teller",
+		Subsystem: "proxy",
+		Name:      "gateway_request_duration_seconds",
+		Help:      "Latency of requests proxied to the daemon gateway, by route",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+
+	metricThrottledRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teller",
+		Subsystem: "proxy",
+		Name:      "throttled_requests_total",
+		Help:      "Total number of requests rejected by the rate limiter, by route",
+	}, []string{"route"})
+
+	metricInFlightRequests = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "teller",
+		Subsystem: "proxy",
+		Name:      "in_flight_requests",
+		Help:      "Number of requests currently being handled, by route",
+	}, []string{"route"})
+
+	metricDepositStateTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teller",
+		Subsystem: "proxy",
+		Name:      "deposit_state_transitions_total",
+		Help:      "Total number of deposit state transitions observed, by state",
+	}, []string{"state"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		metricBindRequests,
+		metricStatusRequests,
+		metricCSRFRequests,
+		metricGatewayErrors,
+		metricGatewayLatency,
+		metricThrottledRequests,
+		metricInFlightRequests,
+		metricDepositStateTransitions,
+	)
+}
+
+// metricsMiddleware wraps an API handler with Prometheus instrumentation:
+// an in-flight gauge and a per-route request counter. It does not track
+// gateway errors or latency, since those should only be attributed to the
+// actual daemon call a handler makes; see instrumentGatewayCall.
+func metricsMiddleware(route string, counter prometheus.Counter, hd http.HandlerFunc) http.HandlerFunc {
+	inFlight := metricInFlightRequests.WithLabelValues(route)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		counter.Inc()
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		hd(w, r)
+	}
+}
+
+// instrumentGatewayCall records gateway_request_duration_seconds and
+// gateway_errors_total for a single call to the daemon gateway, scoped to
+// just that call so that client-side validation failures (bad skyaddr,
+// missing CSRF token, and the like) aren't misattributed as gateway errors.
+func instrumentGatewayCall(route string, call func() error) error {
+	start := time.Now()
+	err := call()
+	metricGatewayLatency.WithLabelValues(route).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metricGatewayErrors.WithLabelValues(route).Inc()
+	}
+	return err
+}
+
+// setupAdminMux builds the mux for the admin listener: /metrics, /healthz and /readyz.
+// These are bound to a separate listener from the public API so that they are not
+// reachable from the public internet.
+func (hs *httpServ) setupAdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthzHandler())
+	mux.HandleFunc("/readyz", readyzHandler(hs))
+
+	return mux
+}
+
+// healthzHandler is a liveness probe: if the process can answer HTTP at all, it's alive.
+func healthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}
+}
+
+// readyzHandler is a readiness probe: not ready until the configured start time has
+// passed and, if a daemon gateway is configured, its connection is up. In
+// WithoutTeller mode hs.Gateway is nil and there's no gateway connection to check.
+func readyzHandler(hs *httpServ) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if time.Now().UTC().Before(hs.StartAt.UTC()) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready: event has not started"))
+			return
+		}
+
+		if hs.Gateway != nil {
+			ctx, cancel := context.WithTimeout(r.Context(), time.Second*5)
+			defer cancel()
+
+			if err := hs.Gateway.Ping(ctx); err != nil {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte("not ready: gateway is not connected"))
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"net"
+
+	"github.com/pires/go-proxyproto"
+)
+
+// listen opens a TCP listener on addr, wrapping it with a PROXY protocol v2
+// parser when hs.ProxyProtocol is enabled so that RemoteAddr reflects the
+// real client IP instead of the load balancer's address. This keeps the
+// tollbooth rate limiter and log middleware working against the real IP.
+func (hs *httpServ) listen(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hs.ProxyProtocol {
+		return ln, nil
+	}
+
+	policy, err := proxyProtocolPolicy(hs.ProxyProtocolTrustedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proxyproto.Listener{
+		Listener: ln,
+		Policy:   policy,
+	}, nil
+}
+
+// proxyProtocolPolicy builds a proxyproto.PolicyFunc that only honors the
+// PROXY protocol header from the given trusted upstream CIDRs. An empty list
+// trusts all upstreams, which is appropriate when the listener is only
+// reachable from the load balancer in the first place (e.g. bound to a
+// private interface).
+func proxyProtocolPolicy(trustedCIDRs []string) (proxyproto.PolicyFunc, error) {
+	if len(trustedCIDRs) == 0 {
+		return func(upstream net.Addr) (proxyproto.Policy, error) {
+			return proxyproto.USE, nil
+		}, nil
+	}
+
+	nets := make([]*net.IPNet, len(trustedCIDRs))
+	for i, cidr := range trustedCIDRs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets[i] = n
+	}
+
+	return func(upstream net.Addr) (proxyproto.Policy, error) {
+		// Untrusted upstreams get IGNORE rather than REJECT: their connection
+		// is still accepted and treated as a normal, non-proxied connection
+		// (RemoteAddr is the upstream's own address), so direct non-LB
+		// traffic such as local health checks keeps working. REJECT would
+		// instead close the connection outright.
+		host, _, err := net.SplitHostPort(upstream.String())
+		if err != nil {
+			return proxyproto.IGNORE, nil
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return proxyproto.IGNORE, nil
+		}
+
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return proxyproto.USE, nil
+			}
+		}
+
+		return proxyproto.IGNORE, nil
+	}, nil
+}
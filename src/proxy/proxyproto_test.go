@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pires/go-proxyproto"
+)
+
+type stubAddr struct {
+	network string
+	s       string
+}
+
+func (a stubAddr) Network() string { return a.network }
+func (a stubAddr) String() string  { return a.s }
+
+func TestProxyProtocolPolicyNoTrustedCIDRsTrustsEverything(t *testing.T) {
+	policy, err := proxyProtocolPolicy(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := policy(stubAddr{network: "tcp", s: "203.0.113.9:1234"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != proxyproto.USE {
+		t.Errorf("expected USE with no trusted CIDRs, got %v", got)
+	}
+}
+
+func TestProxyProtocolPolicyTrustedUpstream(t *testing.T) {
+	policy, err := proxyProtocolPolicy([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := policy(stubAddr{network: "tcp", s: "10.1.2.3:5555"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != proxyproto.USE {
+		t.Errorf("expected USE for trusted upstream, got %v", got)
+	}
+}
+
+func TestProxyProtocolPolicyUntrustedUpstream(t *testing.T) {
+	policy, err := proxyProtocolPolicy([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := policy(stubAddr{network: "tcp", s: "203.0.113.9:1234"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != proxyproto.IGNORE {
+		t.Errorf("expected IGNORE for untrusted upstream, got %v", got)
+	}
+}
+
+func TestProxyProtocolPolicyMalformedUpstreamIgnored(t *testing.T) {
+	policy, err := proxyProtocolPolicy([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// No port, so net.SplitHostPort fails.
+	got, err := policy(stubAddr{network: "tcp", s: "not-an-address"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != proxyproto.IGNORE {
+		t.Errorf("expected IGNORE for malformed upstream, got %v", got)
+	}
+}
+
+func TestProxyProtocolPolicyInvalidCIDRErrors(t *testing.T) {
+	if _, err := proxyProtocolPolicy([]string{"not-a-cidr"}); err == nil {
+		t.Error("expected an error for an invalid CIDR")
+	}
+}
+
+var _ net.Addr = stubAddr{}
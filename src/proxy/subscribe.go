@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// StatusUpdate is a single deposit status transition pushed to subscribers of
+// /api/status/subscribe, replacing the need to poll /api/status.
+type StatusUpdate struct {
+	Seq       int    `json:"seq"`
+	UpdatedAt int64  `json:"updated_at"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+const (
+	subscribeWriteTimeout = time.Second * 10
+	subscribePingPeriod   = time.Second * 30
+)
+
+var subscribeUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// The subscribe handler only ever pushes data, it never trusts anything
+	// read back from the client, so accepting any origin here is safe; the
+	// bind/status APIs remain the trust boundary.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// SubscribeDepositStatus returns a channel of StatusUpdate events for the
+// given skyaddr, fed by gw.d's daemon.StatusPublisher, which the exchange and
+// scanner subsystems publish state transitions to. The channel is closed
+// when ctx is done or the daemon ends the subscription. It backs
+// /api/status/subscribe, replacing the previous client polling pattern
+// against /api/status.
+func (gw *gateway) SubscribeDepositStatus(ctx context.Context, skyaddr string) (<-chan StatusUpdate, error) {
+	updates, err := gw.d.SubscribeDepositStatus(ctx, skyaddr)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StatusUpdate)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case u, ok := <-updates:
+				if !ok {
+					return
+				}
+				select {
+				case out <- StatusUpdate{
+					Seq:       u.Seq,
+					UpdatedAt: u.UpdatedAt,
+					Status:    u.Status,
+					Error:     u.Error,
+				}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// SubscribeStatusHandler upgrades to a WebSocket connection and pushes a
+// StatusUpdate event every time the given skyaddr's deposit state changes,
+// until the connection is closed or the gateway's subscription ends.
+// Method: GET
+// URI: /api/status/subscribe
+// Args:
+//     skyaddr
+func SubscribeStatusHandler(srv *httpServ) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validMethod(w, r, srv.Gateway, []string{http.MethodGet}) {
+			return
+		}
+
+		skyAddr := r.URL.Query().Get("skyaddr")
+		if skyAddr == "" {
+			errorResponse(w, srv.Gateway, http.StatusBadRequest, "Missing skyaddr")
+			return
+		}
+
+		if !verifySkycoinAddress(w, srv.Gateway, skyAddr) {
+			return
+		}
+
+		if !readyToStart(w, srv.Gateway, srv.StartAt) {
+			return
+		}
+
+		ctx := r.Context()
+
+		var updates <-chan StatusUpdate
+		err := instrumentGatewayCall("/api/status/subscribe", func() error {
+			var err error
+			updates, err = srv.Gateway.SubscribeDepositStatus(ctx, skyAddr)
+			return err
+		})
+		if err != nil {
+			handleGatewayResponseError(w, srv.Gateway, err)
+			return
+		}
+
+		conn, err := subscribeUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			srv.Println("Subscribe upgrade error:", err)
+			return
+		}
+		defer conn.Close()
+
+		// Upgrading hijacks the connection out of net/http's control, but the
+		// WriteTimeout deadline set by the server for this request's headers
+		// is still armed on the raw conn. Clear it so this long-lived route
+		// isn't killed by the public listener's request-level WriteTimeout;
+		// subscribeWriteTimeout below re-arms a deadline per message instead.
+		conn.UnderlyingConn().SetDeadline(time.Time{})
+
+		ticker := time.NewTicker(subscribePingPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case update, ok := <-updates:
+				if !ok {
+					return
+				}
+
+				metricDepositStateTransitions.WithLabelValues(update.Status).Inc()
+
+				conn.SetWriteDeadline(time.Now().Add(subscribeWriteTimeout))
+				if err := conn.WriteJSON(update); err != nil {
+					srv.Println("Subscribe write error:", err)
+					return
+				}
+			case <-ticker.C:
+				conn.SetWriteDeadline(time.Now().Add(subscribeWriteTimeout))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}